@@ -0,0 +1,144 @@
+package bitbang
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// pcapng block types and the USER0 link type, see
+// https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-02.html and the
+// tcpdump/libpcap LINKTYPE_ registry.
+const (
+	blockSHB       = 0x0a0d0d0a
+	blockIDB       = 0x00000001
+	blockEPB       = 0x00000006
+	byteOrderMagic = 0x1a2b3c4d
+	linkTypeUSER0  = 147
+	pcapSnapLen    = 65535
+)
+
+// PcapSink writes captured samples to w as a pcapng capture file using the
+// USER0 (147) link type, one raw sample byte per packet record, openable
+// directly in Wireshark. There's no wall clock available to a TapSink, so
+// the packet timestamp is simply the sample index, one nominal tick apart.
+type PcapSink struct {
+	w   io.Writer
+	err error
+}
+
+// NewPcapSink writes a pcapng section header and a single interface
+// description (carrying layout's channel names as a comment, since pcapng
+// has no concept of sub-byte channels) to w, and returns a PcapSink ready
+// to receive samples.
+func NewPcapSink(w io.Writer, layout ChannelLayout) (*PcapSink, error) {
+	s := &PcapSink{w: w}
+	if err := s.writeBlock(blockSHB, shbBody()); err != nil {
+		return nil, err
+	}
+	if err := s.writeBlock(blockIDB, idbBody(layout)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func shbBody() []byte {
+	buf := make([]byte, 16)
+	binary.LittleEndian.PutUint32(buf[0:4], byteOrderMagic)
+	binary.LittleEndian.PutUint16(buf[4:6], 1) // major version
+	binary.LittleEndian.PutUint16(buf[6:8], 0) // minor version
+	binary.LittleEndian.PutUint64(buf[8:16], ^uint64(0))
+	return buf
+}
+
+func idbBody(layout ChannelLayout) []byte {
+	opts := channelComment(layout)
+	buf := make([]byte, 8+len(opts))
+	binary.LittleEndian.PutUint16(buf[0:2], linkTypeUSER0)
+	binary.LittleEndian.PutUint16(buf[2:4], 0) // reserved
+	binary.LittleEndian.PutUint32(buf[4:8], pcapSnapLen)
+	copy(buf[8:], opts)
+	return buf
+}
+
+// channelComment encodes layout as an if_description (opt code 3) pcapng
+// option so a reader at least shows which mask belongs to which channel
+// name, padded to a 32-bit boundary as every option must be.
+func channelComment(layout ChannelLayout) []byte {
+	var s []byte
+	for i, ch := range layout {
+		if i > 0 {
+			s = append(s, ',')
+		}
+		s = append(s, ch.Name...)
+		s = append(s, '=')
+		s = appendHexByte(s, ch.Mask)
+	}
+	if len(s) == 0 {
+		return nil
+	}
+	opt := make([]byte, 4)
+	binary.LittleEndian.PutUint16(opt[0:2], 3) // if_description
+	binary.LittleEndian.PutUint16(opt[2:4], uint16(len(s)))
+	opt = append(opt, s...)
+	opt = append(opt, make([]byte, pad4(len(s)))...)
+	opt = append(opt, 0, 0, 0, 0) // opt_endofopt
+	return opt
+}
+
+func appendHexByte(s []byte, b byte) []byte {
+	const hex = "0123456789abcdef"
+	return append(s, '0', 'x', hex[b>>4], hex[b&0xf])
+}
+
+func pad4(n int) int {
+	if r := n % 4; r != 0 {
+		return 4 - r
+	}
+	return 0
+}
+
+func epbBody(index uint64, b byte) []byte {
+	buf := make([]byte, 20+4)                                  // header fields + 1 data byte padded to 4 bytes.
+	binary.LittleEndian.PutUint32(buf[0:4], 0)                 // interface id
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(index>>32)) // timestamp (high)
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(index))    // timestamp (low)
+	binary.LittleEndian.PutUint32(buf[12:16], 1)               // captured packet length
+	binary.LittleEndian.PutUint32(buf[16:20], 1)               // original packet length
+	buf[20] = b
+	return buf
+}
+
+func (s *PcapSink) writeBlock(blockType uint32, body []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	total := uint32(12 + len(body))
+	buf := make([]byte, 0, total)
+	buf = appendU32(buf, blockType)
+	buf = appendU32(buf, total)
+	buf = append(buf, body...)
+	buf = appendU32(buf, total)
+	_, s.err = s.w.Write(buf)
+	return s.err
+}
+
+func appendU32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+// Sample writes b as one Enhanced Packet Block.
+func (s *PcapSink) Sample(index uint64, _ Dir, b byte) error {
+	return s.writeBlock(blockEPB, epbBody(index, b))
+}
+
+// Flush is a no-op: every Sample call already writes a complete block.
+func (s *PcapSink) Flush() error {
+	return s.err
+}
+
+// Close is a no-op: pcapng files don't need a trailer.
+func (s *PcapSink) Close() error {
+	return s.err
+}
@@ -0,0 +1,42 @@
+package bitbang
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPcapSinkWritesWellFormedBlocks(t *testing.T) {
+	var buf bytes.Buffer
+	sink, err := NewPcapSink(&buf, ChannelLayout{{"SCLK", 0x01}, {"MOSI", 0x10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Sample(0, DirWrite, 0x11); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Sample(1, DirRead, 0x01); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("trailing %d bytes don't form a full block header", len(data))
+		}
+		total := le32(data[4:8])
+		if int(total) > len(data) {
+			t.Fatalf("block claims length %d but only %d bytes remain", total, len(data))
+		}
+		if trailer := le32(data[total-4 : total]); trailer != total {
+			t.Fatalf("block length field mismatch: %d vs trailing %d", total, trailer)
+		}
+		data = data[total:]
+	}
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
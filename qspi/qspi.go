@@ -0,0 +1,195 @@
+// Package qspi implements Single/Dual/Quad SPI (QSPI/DSPI), the
+// multi-lane variant of SPI used by most SPI-NOR flash chips, using bit
+// banging (http://en.wikipedia.org/wiki/Bit_banging). Unlike package spi it
+// fixes the electrical mode to MSBF/CPOL0/CPHA0 (the only mode flash chips
+// use) and instead lets each phase of a transaction (command, address,
+// dummy, data) pick its own lane width, matching the 1-1-1/1-1-4/1-4-4/4-4-4
+// phase combinations flash datasheets describe.
+package qspi
+
+import (
+	"io"
+
+	"github.com/ziutek/bitbang"
+)
+
+// Width is the number of data lines driven/sampled per clock cycle during
+// one phase of a transaction.
+type Width int
+
+const (
+	Width1 Width = 1 // Single: IO0 is MOSI, IO1 is MISO (classic SPI).
+	Width2 Width = 2 // Dual: IO0 and IO1 both carry data.
+	Width4 Width = 4 // Quad: IO0..IO3 all carry data.
+)
+
+// QuadMaster implements the master side of Single/Dual/Quad SPI.
+type QuadMaster struct {
+	drv  bitbang.SyncDriver
+	sclk byte
+	io   [4]byte
+	base byte
+}
+
+// NewQuadMaster returns a new QuadMaster that uses drv to read/write data.
+// sclk is the clock line mask and io are the masks of IO0..IO3 (IO0/IO1
+// double as MOSI/MISO in Width1 and Width2 phases). NewQuadMaster panics if
+// any two masks overlap.
+func NewQuadMaster(drv bitbang.SyncDriver, sclk byte, io [4]byte) *QuadMaster {
+	used := sclk
+	for _, m := range io {
+		if used&m != 0 {
+			panic("qspi: overlapping line masks")
+		}
+		used |= m
+	}
+	return &QuadMaster{drv: drv, sclk: sclk, io: io}
+}
+
+// SetBase sets the bits that are ORed into every word written to the
+// driver, for example to keep a chip-select line asserted for the duration
+// of a transaction.
+func (ma *QuadMaster) SetBase(base byte) {
+	ma.base = base
+}
+
+// Base returns the value set by SetBase.
+func (ma *QuadMaster) Base() byte {
+	return ma.base
+}
+
+// Flush calls the driver's Flush method.
+func (ma *QuadMaster) Flush() error {
+	return ma.drv.Flush()
+}
+
+// clockOut drives the low width bits of v for one SCLK cycle, bit i on IOi
+// (so in Width1 only IO0, the MOSI line, carries v). It reads back and discards the bytes the
+// driver echoes for the write, honoring bitbang.SyncDriver's one-write-one-
+// read contract so a later Read starts at the right place in the stream
+// instead of consuming stale bytes left over from this write.
+func (ma *QuadMaster) clockOut(v uint, width Width) error {
+	bits := make([]byte, 0, 2)
+	level := ma.base
+	for lane := int(width) - 1; lane >= 0; lane-- {
+		if v&(1<<uint(lane)) != 0 {
+			level |= ma.io[lane]
+		}
+	}
+	bits = append(bits, level, level^ma.sclk)
+	if _, err := ma.drv.Write(bits); err != nil {
+		return err
+	}
+	_, err := ma.readLevel()
+	return err
+}
+
+// writeByte clocks out one byte of data using width data lines per cycle.
+func (ma *QuadMaster) writeByte(b byte, width Width) error {
+	for shift := 8 - int(width); shift >= 0; shift -= int(width) {
+		v := uint(b>>uint(shift)) & ((1 << uint(width)) - 1)
+		if err := ma.clockOut(v, width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write clocks out data using width data lines per cycle, eg. width ==
+// Width1 drives only IO0 (MOSI), width == Width4 drives IO0..IO3
+// simultaneously.
+func (ma *QuadMaster) Write(width Width, data []byte) (int, error) {
+	for k, b := range data {
+		if err := ma.writeByte(b, width); err != nil {
+			return k, err
+		}
+	}
+	return len(data), nil
+}
+
+// Dummy clocks the given number of cycles with all data lines released (driven low) so a
+// slave can turn its outputs around before a read phase, as required
+// between the address and data phases of fast/quad read commands.
+func (ma *QuadMaster) Dummy(width Width, cycles int) error {
+	for i := 0; i < cycles; i++ {
+		if err := ma.clockOut(0, width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readByte clocks one byte of data in using width data lines per cycle,
+// sampling the IO lines after the rising edge of SCLK (CPHA0).
+func (ma *QuadMaster) readByte(width Width) (byte, error) {
+	var u uint
+	var bits [2]byte
+	for n := 0; n < 8; n += int(width) {
+		level := ma.base
+		bits[0] = level
+		bits[1] = level ^ ma.sclk
+		if _, err := ma.drv.Write(bits[:]); err != nil {
+			return 0, err
+		}
+		in, err := ma.readLevel()
+		if err != nil {
+			return 0, err
+		}
+		v := uint(0)
+		for lane := 0; lane < int(width); lane++ {
+			io := lane
+			if width == Width1 {
+				io = 1 // IO0 is MOSI; IO1 is MISO in single-lane mode.
+			}
+			if in&ma.io[io] != 0 {
+				v |= 1 << uint(lane)
+			}
+		}
+		u = u<<uint(width) | v
+	}
+	return byte(u), nil
+}
+
+// readLevel reads back the byte sampled by the driver on the edge just
+// written (the second of the two levels clockOut/readByte produce).
+func (ma *QuadMaster) readLevel() (byte, error) {
+	var b [2]byte
+	n, err := io.ReadFull(ma.drv, b[:])
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if n != len(b) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return b[1], nil
+}
+
+// Read reads len(data) bytes using width data lines per cycle, sampling
+// IO0..IO3 (or just IO0/IO1, or just IO1, the MISO line, in Width1 mode).
+func (ma *QuadMaster) Read(width Width, data []byte) (int, error) {
+	for k := range data {
+		b, err := ma.readByte(width)
+		if err != nil {
+			return k, err
+		}
+		data[k] = b
+	}
+	return len(data), nil
+}
+
+// Command clocks out a one-byte command using width data lines per cycle.
+// Most flash commands send the command byte itself in Width1 regardless of
+// the width used for the address/data phases that follow (eg. 1-1-4).
+func (ma *QuadMaster) Command(width Width, cmd byte) error {
+	return ma.writeByte(cmd, width)
+}
+
+// Address clocks out addr (typically 3 or 4 bytes, MSB first) using width
+// data lines per cycle.
+func (ma *QuadMaster) Address(width Width, addr []byte) error {
+	_, err := ma.Write(width, addr)
+	return err
+}
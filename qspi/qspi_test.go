@@ -0,0 +1,169 @@
+package qspi
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testdrv struct {
+	*bytes.Buffer
+}
+
+func (testdrv) Flush() error { return nil }
+
+// rwdrv is a testdrv with independent read and write buffers, needed for
+// Read/readByte tests: unlike testdrv, it doesn't echo back what the master
+// itself just wrote, so r can hold a canned slave response.
+type rwdrv struct {
+	r *bytes.Buffer
+	w *bytes.Buffer
+}
+
+func (d rwdrv) Read(p []byte) (int, error)  { return d.r.Read(p) }
+func (d rwdrv) Write(p []byte) (int, error) { return d.w.Write(p) }
+func (rwdrv) Flush() error                  { return nil }
+
+func TestCommandWidth1(t *testing.T) {
+	// clockOut reads back and discards one echoed byte pair per cycle (see
+	// the review fix in chunk0-2's follow-up commit); r just needs to be
+	// long enough to satisfy those reads, its content is irrelevant here.
+	drv := rwdrv{r: bytes.NewBuffer(make([]byte, 16)), w: new(bytes.Buffer)}
+	ma := NewQuadMaster(drv, 0x01, [4]byte{0x10, 0x20, 0x40, 0x80})
+	if err := ma.Command(Width1, 0x9f); err != nil {
+		t.Fatal(err)
+	}
+	// 0x9f = 1001_1111b, MSB first.
+	want := []byte{
+		0x10, 0x11,
+		0x00, 0x01,
+		0x00, 0x01,
+		0x10, 0x11,
+		0x10, 0x11,
+		0x10, 0x11,
+		0x10, 0x11,
+		0x10, 0x11,
+	}
+	if !bytes.Equal(drv.w.Bytes(), want) {
+		t.Errorf("got  %#v\nwant %#v", drv.w.Bytes(), want)
+	}
+}
+
+func TestWriteWidth4(t *testing.T) {
+	drv := rwdrv{r: bytes.NewBuffer(make([]byte, 4)), w: new(bytes.Buffer)}
+	ma := NewQuadMaster(drv, 0x01, [4]byte{0x10, 0x20, 0x40, 0x80})
+	if _, err := ma.Write(Width4, []byte{0xa5}); err != nil {
+		t.Fatal(err)
+	}
+	// 0xa5 = 1010_0101, sent as two nibbles: 0xa then 0x5.
+	want := []byte{
+		0xa0, 0xa1, 0x50, 0x51,
+	}
+	if !bytes.Equal(drv.w.Bytes(), want) {
+		t.Errorf("got  %#v\nwant %#v", drv.w.Bytes(), want)
+	}
+}
+
+func TestDummyReleasesLines(t *testing.T) {
+	drv := rwdrv{r: bytes.NewBuffer(make([]byte, 4)), w: new(bytes.Buffer)}
+	ma := NewQuadMaster(drv, 0x01, [4]byte{0x10, 0x20, 0x40, 0x80})
+	if err := ma.Dummy(Width4, 2); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x00, 0x01, 0x00, 0x01}
+	if !bytes.Equal(drv.w.Bytes(), want) {
+		t.Errorf("got  %#v\nwant %#v", drv.w.Bytes(), want)
+	}
+}
+
+// TestWriteDrainsEcho pins the chunk0-2 follow-up fix: clockOut must read
+// back the byte it just wrote before the next phase starts, or a read
+// immediately following a write/command/address/dummy phase would consume
+// those stale echoed bytes instead of its own. A drv whose r is exactly as
+// long as what Command+Address+Dummy produce, followed by canned Read data,
+// proves Read starts at the right offset.
+func TestWriteDrainsEcho(t *testing.T) {
+	stale := make([]byte, 16+3*2*8+2*8) // Command(Width1) + Address(Width1, 3 bytes) + Dummy(Width1, 8).
+	for i := range stale {
+		stale[i] = 0xff // Would decode as garbage if Read ever consumed it.
+	}
+	// 0xa5 = 1010_0101b, MSBF, driven on IO1 (MISO) for the Width1 Read that follows.
+	readBack := []byte{
+		0x00, 0x20,
+		0x00, 0x00,
+		0x00, 0x20,
+		0x00, 0x00,
+		0x00, 0x00,
+		0x00, 0x20,
+		0x00, 0x00,
+		0x00, 0x20,
+	}
+	drv := rwdrv{r: bytes.NewBuffer(append(stale, readBack...)), w: new(bytes.Buffer)}
+	ma := NewQuadMaster(drv, 0x01, [4]byte{0x10, 0x20, 0x40, 0x80})
+
+	if err := ma.Command(Width1, 0x9f); err != nil {
+		t.Fatal(err)
+	}
+	if err := ma.Address(Width1, []byte{0x00, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ma.Dummy(Width1, 8); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 1)
+	if _, err := ma.Read(Width1, got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 0xa5 {
+		t.Errorf("Read(Width1) after Command+Address+Dummy = %#x, want 0xa5", got[0])
+	}
+}
+
+func TestReadWidth1(t *testing.T) {
+	// 0xa5 = 1010_0101b, MSBF, driven back on IO1 (MISO) only: IO0 carries
+	// MOSI, which is what readByte must NOT sample in Width1 mode.
+	r := []byte{
+		0x00, 0x20,
+		0x00, 0x00,
+		0x00, 0x20,
+		0x00, 0x00,
+		0x00, 0x00,
+		0x00, 0x20,
+		0x00, 0x00,
+		0x00, 0x20,
+	}
+	drv := rwdrv{r: bytes.NewBuffer(r), w: new(bytes.Buffer)}
+	ma := NewQuadMaster(drv, 0x01, [4]byte{0x10, 0x20, 0x40, 0x80})
+	got := make([]byte, 1)
+	if _, err := ma.Read(Width1, got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 0xa5 {
+		t.Errorf("Read(Width1) = %#x, want 0xa5", got[0])
+	}
+}
+
+func TestReadWidth4(t *testing.T) {
+	// 0xa5 sent as two nibbles, 0xa then 0x5, across IO0..IO3.
+	r := []byte{
+		0x00, 0xa0,
+		0x00, 0x50,
+	}
+	drv := rwdrv{r: bytes.NewBuffer(r), w: new(bytes.Buffer)}
+	ma := NewQuadMaster(drv, 0x01, [4]byte{0x10, 0x20, 0x40, 0x80})
+	got := make([]byte, 1)
+	if _, err := ma.Read(Width4, got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 0xa5 {
+		t.Errorf("Read(Width4) = %#x, want 0xa5", got[0])
+	}
+}
+
+func TestOverlappingMasksPanic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for overlapping masks")
+		}
+	}()
+	NewQuadMaster(testdrv{bytes.NewBuffer(nil)}, 0x01, [4]byte{0x01, 0x20, 0x40, 0x80})
+}
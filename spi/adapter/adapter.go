@@ -0,0 +1,135 @@
+// Package adapter wraps *spi.Master with a small, driver-facing interface
+// similar to the Bus/Device split used by other embedded HAL ecosystems
+// (periph.io, tinygo drivers, Rust's embedded-hal). It lets third-party
+// drivers written against that shape of API run unmodified on top of a
+// bit-banged SPI master.
+package adapter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ziutek/bitbang/spi"
+)
+
+// Bus performs a single SPI transfer on an already-selected device. It does
+// not know about chip select: callers that need to assert/deassert CS
+// around a transfer should use Device instead.
+type Bus struct {
+	ma *spi.Master
+	mu sync.Mutex // serializes Transfer/TransferContext, including abandoned ones left running past a cancel.
+}
+
+// NewBus returns a Bus that shifts data using ma.
+func NewBus(ma *spi.Master) *Bus {
+	return &Bus{ma: ma}
+}
+
+// Transfer writes tx while simultaneously reading len(rx) bytes into rx. It
+// is equivalent to ma.WriteRead(tx, rx) and brackets the whole transfer in
+// its own Begin:End block.
+func (b *Bus) Transfer(tx, rx []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := b.ma.WriteRead(tx, rx)
+	return err
+}
+
+// TransferContext works like Transfer but returns ctx.Err() as soon as ctx
+// is done, without waiting for the underlying transfer to finish. This is
+// useful with slow adapters (eg. FT232H) where a single Transfer can take
+// many milliseconds: the driver goroutine below keeps running against ma,
+// still holding the Bus's mutex, and its result is discarded once the caller
+// has moved on. Because of that, tx and rx remain in use by that goroutine
+// until it finishes; they must not be reused until a later call to Transfer
+// on the same Bus has returned, or a later TransferContext has returned
+// something other than its own ctx.Err(): either only happens once any
+// abandoned transfer has actually completed.
+func (b *Bus) TransferContext(ctx context.Context, tx, rx []byte) error {
+	done := make(chan error, 1)
+	go func() { done <- b.Transfer(tx, rx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Select asserts (true) or deasserts (false) chip select for a Device. It is
+// usually backed by a GPIO line or, when CS shares the same bit-banged word
+// as SCLK/MOSI/MISO, by ma.SetBase.
+type Select func(asserted bool) error
+
+// Device combines a Bus with the chip-select and timing a particular slave
+// needs, so a full transaction can be driven with a single Transfer call:
+// select, optional pre-delay, data phase, optional post-delay, deselect.
+type Device struct {
+	Bus    *Bus
+	Select Select
+	Pre    time.Duration
+	Post   time.Duration
+}
+
+// NewDevice returns a Device that transfers over bus and drives sel around
+// each transaction.
+func NewDevice(bus *Bus, sel Select) *Device {
+	return &Device{Bus: bus, Select: sel}
+}
+
+// Transfer asserts chip select, waits Pre, performs the data phase, waits
+// Post and deasserts chip select. If Select(true) fails the data phase is
+// skipped. The deselect error is returned only if the transfer itself
+// succeeded.
+func (d *Device) Transfer(tx, rx []byte) error {
+	if err := d.Select(true); err != nil {
+		return err
+	}
+	if d.Pre > 0 {
+		time.Sleep(d.Pre)
+	}
+	err := d.Bus.Transfer(tx, rx)
+	if d.Post > 0 {
+		time.Sleep(d.Post)
+	}
+	if derr := d.Select(false); err == nil {
+		err = derr
+	}
+	return err
+}
+
+// TransferContext works like Transfer but returns ctx.Err() early if ctx is
+// done before the transfer completes. Select(true) is still called
+// synchronously up front, but Post and Select(false) are deferred to a
+// background goroutine that runs the data phase and the rest of the
+// cleanup together: that way CS is never deasserted while the data phase
+// is still in flight, whether or not ctx was cancelled. Once ctx is done,
+// the caller must not reuse tx/rx until that goroutine has actually
+// finished, eg. by waiting for a subsequent Transfer call on the same Bus to
+// return (see Bus.TransferContext).
+func (d *Device) TransferContext(ctx context.Context, tx, rx []byte) error {
+	if err := d.Select(true); err != nil {
+		return err
+	}
+	if d.Pre > 0 {
+		time.Sleep(d.Pre)
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := d.Bus.Transfer(tx, rx)
+		if d.Post > 0 {
+			time.Sleep(d.Post)
+		}
+		if derr := d.Select(false); err == nil {
+			err = derr
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
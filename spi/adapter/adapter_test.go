@@ -0,0 +1,152 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ziutek/bitbang/spi"
+)
+
+// testdrv loops written bytes back as the bytes read, which is enough to
+// drive a Master with no real slave attached. It must guard the shared
+// buffer with a mutex: spi.Master.WriteRead writes and reads concurrently,
+// in separate goroutines, against the very same drv.
+type testdrv struct {
+	mu  sync.Mutex
+	buf *bytes.Buffer
+}
+
+func (d *testdrv) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.buf.Read(p)
+}
+
+func (d *testdrv) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.buf.Write(p)
+}
+
+func (*testdrv) Flush() error { return nil }
+
+func newMaster() *spi.Master {
+	ma := spi.NewMaster(&testdrv{buf: bytes.NewBuffer(nil)}, 0x01, 0x10, 0)
+	ma.Configure(spi.Config{Mode: spi.MSBF | spi.CPOL0 | spi.CPHA0, FrameLen: 1})
+	return ma
+}
+
+// pipeDrv loops Write back into Read through a real io.Pipe, so (unlike
+// testdrv) Read genuinely blocks until the matching Write lands instead of
+// returning a premature EOF, and an added delay can reliably simulate a slow
+// adapter without racing the in-memory buffer.
+type pipeDrv struct {
+	r     *io.PipeReader
+	w     *io.PipeWriter
+	delay time.Duration
+}
+
+func (d *pipeDrv) Read(p []byte) (int, error) {
+	if d.delay > 0 {
+		time.Sleep(d.delay)
+	}
+	return d.r.Read(p)
+}
+
+func (d *pipeDrv) Write(p []byte) (int, error) { return d.w.Write(p) }
+
+func (*pipeDrv) Flush() error { return nil }
+
+func newSlowMaster(delay time.Duration) *spi.Master {
+	r, w := io.Pipe()
+	ma := spi.NewMaster(&pipeDrv{r: r, w: w, delay: delay}, 0x01, 0x10, 0)
+	ma.Configure(spi.Config{Mode: spi.MSBF | spi.CPOL0 | spi.CPHA0, FrameLen: 1})
+	return ma
+}
+
+func TestBusTransfer(t *testing.T) {
+	bus := NewBus(newMaster())
+	rx := make([]byte, 2)
+	if err := bus.Transfer([]byte{0x55, 0xaa}, rx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceTransferSelectsAroundTransfer(t *testing.T) {
+	var got []bool
+	sel := func(asserted bool) error {
+		got = append(got, asserted)
+		return nil
+	}
+	dev := NewDevice(NewBus(newMaster()), sel)
+	if err := dev.Transfer([]byte{0x55}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{true, false}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Select calls = %v, want %v", got, want)
+	}
+}
+
+func TestDeviceTransferSelectError(t *testing.T) {
+	errSel := errors.New("select error")
+	dev := NewDevice(NewBus(newMaster()), func(bool) error { return errSel })
+	if err := dev.Transfer([]byte{0x55}, nil); err != errSel {
+		t.Fatalf("err = %v, want %v", err, errSel)
+	}
+}
+
+func TestBusTransferContextCancel(t *testing.T) {
+	bus := NewBus(newMaster())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	time.Sleep(time.Millisecond) // ctx is already done before TransferContext starts its driver goroutine.
+	if err := bus.TransferContext(ctx, []byte{0x55}, nil); err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestDeviceTransferContextCancelDoesNotWaitForPost checks that a cancelled
+// ctx shortens the call: Post (now run in the background, alongside
+// Select(false)) must not be waited on once ctx is already done, or
+// cancellation would be pointless.
+func TestDeviceTransferContextCancelDoesNotWaitForPost(t *testing.T) {
+	dev := NewDevice(NewBus(newMaster()), func(bool) error { return nil })
+	dev.Post = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	time.Sleep(time.Millisecond) // ctx is already done before TransferContext starts its driver goroutine.
+
+	start := time.Now()
+	if err := dev.TransferContext(ctx, []byte{0x55}, nil); err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed >= dev.Post {
+		t.Fatalf("TransferContext took %v, want well under Post (%v)", elapsed, dev.Post)
+	}
+}
+
+// TestDeviceTransferContextCancelThenReuseRx pins the chunk0-1 follow-up
+// fix: once TransferContext returns ctx.Err(), its background goroutine is
+// still running the real transfer against tx/rx. Run with -race: a second
+// Transfer reusing those same buffers must block on the Bus's mutex until
+// the abandoned transfer actually finishes, not race with it.
+func TestDeviceTransferContextCancelThenReuseRx(t *testing.T) {
+	dev := NewDevice(NewBus(newSlowMaster(5*time.Millisecond)), func(bool) error { return nil })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tx := []byte{0x55}
+	rx := make([]byte, 1)
+	if err := dev.TransferContext(ctx, tx, rx); err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if err := dev.Transfer(tx, rx); err != nil {
+		t.Fatal(err)
+	}
+}
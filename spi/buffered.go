@@ -0,0 +1,159 @@
+package spi
+
+import "io"
+
+// wbdesc describes one WriteBuffered batch pending on the read side: n data
+// bytes, whose corresponding delay bits (if any) were inserted every flen
+// bytes starting fn bytes into the current delay cycle, exactly mirroring
+// the bookkeeping writeBits does for the streaming Write. A single wbdesc
+// lets ReadBuffered replay that placement without needing one descriptor
+// per input byte.
+type wbdesc struct {
+	n    int
+	flen int
+	dlyn int
+	fn   int
+}
+
+// expand appends the 16-byte (2 per bit) expansion of every byte in data to
+// buf, inserting a delay frame of 2*ma.dlyn idle bytes every flen bytes
+// (tracked in ma.fn, shared with the streaming Write/WriteN path), and
+// returns the grown slice. It is the batched counterpart of the per-byte
+// tobits + writeBits loop Write uses.
+func (ma *Master) expand(buf, data []byte, flen int) []byte {
+	var bits [16]byte
+	var idle [16]byte
+	for _, b := range data {
+		if ma.dlyn > 0 {
+			if ma.fn == flen {
+				lvl := ma.base | ma.cidle
+				for i := range idle {
+					idle[i] = lvl
+				}
+				buf = append(buf, idle[:2*ma.dlyn]...)
+				ma.fn = 0
+			}
+			ma.fn++
+		}
+		ma.tobits(&bits, b)
+		buf = append(buf, bits[:]...)
+	}
+	return buf
+}
+
+// toreadBuffered informs ReadBuffered about one WriteBuffered batch.
+func (ma *Master) toreadBuffered(d wbdesc) error {
+	if len(ma.tordb) == cap(ma.tordb) {
+		if err := ma.drv.Flush(); err != nil {
+			return err
+		}
+	}
+	ma.tordb <- d
+	return nil
+}
+
+// WriteBuffered writes data to the SPI bus like Write, but expands the
+// whole input into a single scratch buffer and issues one Driver.Write call
+// for it, instead of one per input byte, and pushes a single descriptor
+// describing the whole batch instead of one per byte. This trades the
+// streaming, low-memory behavior of Write for much lower per-byte
+// overhead, which matters on adapters where a single USB frame can carry
+// many KiB (eg. FT232H): there, the per-byte channel synchronization Write
+// relies on to pace Read becomes the bottleneck.
+//
+// WriteBuffered does not use Begin/End: it drives the bus directly (CS, if
+// any, is the caller's concern, eg. via SetBase) and does not emit pre/post
+// bytes or a CPHA1 idle level. Use the streaming Write inside a Begin:End
+// block if you need those. Pair WriteBuffered with ReadBuffered, not
+// Read/ReadN: mixing them for the same batch will misalign the stream,
+// since ReadBuffered consumes its own descriptor queue, separate from the
+// one Read/ReadN use.
+func (ma *Master) WriteBuffered(data []byte) (int, error) {
+	ma.wmtx.Lock()
+	if ma.werr != nil {
+		err := ma.werr
+		ma.wmtx.Unlock()
+		return 0, err
+	}
+	if len(data) == 0 {
+		ma.wmtx.Unlock()
+		return 0, nil
+	}
+	flen := ma.flen
+	if flen < 0 {
+		flen = -flen
+	}
+	d := wbdesc{n: len(data), flen: flen, dlyn: ma.dlyn, fn: ma.fn}
+	ma.wbbuf = ma.expand(ma.wbbuf[:0], data, flen)
+	if err := ma.toreadBuffered(d); err != nil {
+		ma.werror(err)
+		return 0, err
+	}
+	if _, err := ma.drv.Write(ma.wbbuf); err != nil {
+		ma.werror(err)
+		return 0, err
+	}
+	ma.wmtx.Unlock()
+	return len(data), nil
+}
+
+// nextwb blocks until ma.curwb has at least one byte left to decode,
+// pulling the next descriptor pushed by WriteBuffered if needed.
+func (ma *Master) nextwb() error {
+	for ma.curwb.n == 0 {
+		if ma.tordb == nil {
+			return ma.werr
+		}
+		d, ok := <-ma.tordb
+		if !ok {
+			return ma.werr
+		}
+		ma.curwb = d
+	}
+	return nil
+}
+
+// ReadBuffered reads bytes produced by a prior WriteBuffered call, decoding
+// the same delay placement writeBits/Read use but without a descriptor per
+// byte. It always reads len(data) bytes or returns error (like io.ReadFull).
+func (ma *Master) ReadBuffered(data []byte) (m int, err error) {
+	var bits [16]byte
+	var idle [16]byte
+	for m < len(data) {
+		if err = ma.nextwb(); err != nil {
+			return
+		}
+		if ma.curwb.dlyn > 0 && ma.curwb.fn == ma.curwb.flen {
+			if _, err = io.ReadFull(ma.drv, idle[:2*ma.curwb.dlyn]); err != nil {
+				return
+			}
+			ma.curwb.fn = 0
+		}
+		if err = ma.readBits(&bits); err != nil {
+			return
+		}
+		data[m] = ma.tobyte(&bits)
+		ma.curwb.fn++
+		ma.curwb.n--
+		m++
+	}
+	return
+}
+
+// writeBufferedAsync runs WriteBuffered in its own goroutine for
+// WriteReadBuffered, the same way write does for WriteRead. A failure ends
+// up on ma.werr and is observed by the following ReadBuffered call.
+func (ma *Master) writeBufferedAsync(data []byte) {
+	ma.WriteBuffered(data)
+}
+
+// WriteReadBuffered performs a full-duplex transfer using the buffered fast
+// path for both directions: it calls WriteBuffered(tx) concurrently with
+// ReadBuffered(rx), the same pairing WriteRead uses for the streaming API.
+// Unlike WriteRead it does not pad or discard to reconcile different
+// lengths: the caller picks len(tx) and len(rx) to match what the slave is
+// expected to produce.
+func (ma *Master) WriteReadBuffered(tx, rx []byte) (int, error) {
+	go ma.writeBufferedAsync(tx)
+	return ma.ReadBuffered(rx)
+}
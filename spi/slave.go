@@ -0,0 +1,186 @@
+package spi
+
+import (
+	"io"
+
+	"github.com/ziutek/bitbang"
+)
+
+// Slave implements the Serial Peripheral Interface protocol on the slave
+// side: it consumes an incoming SCLK/MOSI/CS stream from a
+// bitbang.SyncDriver and produces MISO in response. It is mainly useful for
+// testing Master (or anything else that speaks this package's word format)
+// without real hardware, and for turning an adapter like FT232H into a
+// cheap SPI slave analyzer.
+type Slave struct {
+	drv                  bitbang.SyncDriver
+	sclk, mosi, miso, cs byte
+	lsbf                 bool
+}
+
+// NewSlave returns a new Slave that uses drv to read the incoming
+// SCLK/MOSI/CS stream and write MISO. NewSlave panics if any two of the
+// sclk, mosi and cs masks overlap; miso is not checked against them.
+func NewSlave(drv bitbang.SyncDriver, sclk, mosi, miso, cs byte) *Slave {
+	if sclk&mosi != 0 || sclk&cs != 0 || mosi&cs != 0 {
+		panic("spi: overlapping line masks")
+	}
+	return &Slave{drv: drv, sclk: sclk, mosi: mosi, miso: miso, cs: cs}
+}
+
+// Configure configures the slave. It accepts the same Config type as
+// Master for symmetry, but only the LSBF bit of Mode matters here: CPOL and
+// CPHA only affect how SCLK idles and toggles, which is the bus master's
+// responsibility, not the slave's.
+func (sl *Slave) Configure(cfg Config) {
+	sl.lsbf = cfg.Mode&LSBF != 0
+}
+
+// sbits composes the 16 raw bytes (2 per bit) that drive MISO with b,
+// MSBF/LSBF as configured. It deliberately leaves SCLK/MOSI/CS clear: those
+// lines are inputs for a Slave, so their value in the written word is a
+// don't-care for the real driver underneath.
+func (sl *Slave) sbits(bits *[16]byte, b byte) {
+	u := uint(b)
+	mask := uint(0x80)
+	if sl.lsbf {
+		mask = 0x01
+	}
+	for i := 0; i < len(bits); i += 2 {
+		var lvl byte
+		if mask&u != 0 {
+			lvl = sl.miso
+		}
+		bits[i] = lvl
+		bits[i+1] = lvl
+		if sl.lsbf {
+			u >>= 1
+		} else {
+			u <<= 1
+		}
+	}
+}
+
+// rbyte decodes the byte MOSI carried over the 16 raw bytes (2 per bit)
+// sampled for one byte time, MSBF/LSBF as configured.
+func (sl *Slave) rbyte(bits *[16]byte) byte {
+	var u uint
+	if sl.lsbf {
+		for i := 1; i < len(bits); i += 2 {
+			u >>= 1
+			if bits[i]&sl.mosi != 0 {
+				u |= 0x80
+			}
+		}
+	} else {
+		for i := 1; i < len(bits); i += 2 {
+			u <<= 1
+			if bits[i]&sl.mosi != 0 {
+				u |= 0x01
+			}
+		}
+	}
+	return byte(u)
+}
+
+// waitCS blocks until CS is sampled asserted. Like shiftByte, it must write
+// one MISO byte (idle, since nothing has been selected yet) for every byte
+// it reads: bitbang.SyncDriver guarantees one byte back per byte written,
+// and a real Master's Read accounts for every byte it wrote, including the
+// pre/post framing bytes waitCS is the one reading back here.
+func (sl *Slave) waitCS() error {
+	var out, in [1]byte
+	for {
+		if _, err := sl.drv.Write(out[:]); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(sl.drv, in[:]); err != nil {
+			return err
+		}
+		if in[0]&sl.cs != 0 {
+			return nil
+		}
+	}
+}
+
+// shiftByte drives b onto MISO for one byte time and returns the byte
+// sampled from MOSI together with whether CS is still asserted afterwards.
+func (sl *Slave) shiftByte(bits *[16]byte, b byte) (in byte, csOn bool, err error) {
+	sl.sbits(bits, b)
+	if _, err = sl.drv.Write(bits[:]); err != nil {
+		return 0, false, err
+	}
+	if _, err = io.ReadFull(sl.drv, bits[:]); err != nil {
+		return 0, false, err
+	}
+	return sl.rbyte(bits), bits[len(bits)-1]&sl.cs != 0, nil
+}
+
+// Transaction blocks until CS is asserted, then shifts one CS-framed frame:
+// it writes tx to MISO (zero past the end of tx) while reading bytes
+// sampled from MOSI into rx, for max(len(tx), len(rx)) byte times or until
+// CS deasserts, whichever comes first. It returns the number of bytes
+// shifted.
+func (sl *Slave) Transaction(tx, rx []byte) (int, error) {
+	if err := sl.waitCS(); err != nil {
+		return 0, err
+	}
+	n := len(rx)
+	if len(tx) > n {
+		n = len(tx)
+	}
+	var bits [16]byte
+	for k := 0; k < n; k++ {
+		var b byte
+		if k < len(tx) {
+			b = tx[k]
+		}
+		in, csOn, err := sl.shiftByte(&bits, b)
+		if err != nil {
+			return k, err
+		}
+		if !csOn {
+			return k, nil
+		}
+		if k < len(rx) {
+			rx[k] = in
+		}
+	}
+	return n, nil
+}
+
+// Listen repeatedly services CS-framed frames, calling handler once per
+// completed frame with the bytes captured from MOSI. Because handler only
+// runs once the frame that carried rx is already over, the tx it returns is
+// driven onto MISO starting with the *next* frame, not the one that
+// produced rx; this fits protocols that are tested frame by frame, eg. a
+// command sent in frame N answered in frame N+1. Listen runs until handler
+// or the underlying driver returns a non-nil error.
+func (sl *Slave) Listen(handler func(rx []byte) (tx []byte, err error)) error {
+	var tx []byte
+	var bits [16]byte
+	for {
+		if err := sl.waitCS(); err != nil {
+			return err
+		}
+		var rx []byte
+		for k := 0; ; k++ {
+			var b byte
+			if k < len(tx) {
+				b = tx[k]
+			}
+			in, csOn, err := sl.shiftByte(&bits, b)
+			if err != nil {
+				return err
+			}
+			if !csOn {
+				break
+			}
+			rx = append(rx, in)
+		}
+		var err error
+		if tx, err = handler(rx); err != nil {
+			return err
+		}
+	}
+}
@@ -75,6 +75,11 @@ type Master struct {
 	lsbf   bool
 	flen   int
 	dlyn   int
+
+	// For WriteBuffered/WriteReadBuffered (see buffered.go).
+	tordb chan wbdesc
+	wbbuf []byte
+	curwb wbdesc
 }
 
 // New returns new SPI that uses r/w to read/write data using SPI protocol.
@@ -90,11 +95,12 @@ func NewMaster(drv bitbang.SyncDriver, sclk, mosi, miso byte) *Master {
 	}
 	ma := new(Master)
 	*ma = Master{
-		drv:  drv,
-		tord: make(chan int8, 4096/16), // Good value for 4 KiB write buf.
-		sclk: sclk,
-		mosi: mosi,
-		miso: miso,
+		drv:   drv,
+		tord:  make(chan int8, 4096/16), // Good value for 4 KiB write buf.
+		tordb: make(chan wbdesc, 64),
+		sclk:  sclk,
+		mosi:  mosi,
+		miso:  miso,
 	}
 	return ma
 }
@@ -157,6 +163,8 @@ func (ma *Master) werror(err error) {
 	ma.werr = err
 	close(ma.tord)
 	ma.tord = nil
+	close(ma.tordb)
+	ma.tordb = nil
 	ma.wmtx.Unlock()
 }
 
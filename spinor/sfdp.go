@@ -0,0 +1,97 @@
+package spinor
+
+import (
+	"errors"
+	"sort"
+)
+
+// errNoSFDP is returned by DetectGeometry when the chip doesn't respond
+// with a valid SFDP signature.
+var errNoSFDP = errors.New("spinor: no SFDP table")
+
+func (d *Dev) readSFDP(addr uint32, buf []byte) error {
+	cmd := append(addr3(cmdRDSFDP, addr), 0) // one dummy byte after the address.
+	_, err := d.ma.WriteRead(cmd, nil, nil, buf)
+	return err
+}
+
+// DetectGeometry reads the chip's SFDP table, if present, and refines the
+// page and erase sizes used by Program and Erase from its mandatory JEDEC
+// Basic Flash Parameter Table (JESD216). It returns errNoSFDP if the chip
+// doesn't answer with a valid SFDP signature or its table lists no Basic
+// Flash Parameter Table.
+func (d *Dev) DetectGeometry() error {
+	var hdr [8]byte
+	if err := d.readSFDP(0, hdr[:]); err != nil {
+		return err
+	}
+	if string(hdr[:4]) != "SFDP" {
+		return errNoSFDP
+	}
+	nph := int(hdr[6]) + 1
+	for i := 0; i < nph; i++ {
+		var ph [8]byte
+		if err := d.readSFDP(uint32(8+i*8), ph[:]); err != nil {
+			return err
+		}
+		if ph[0] != 0x00 || ph[7] != 0xff {
+			continue // Not the mandatory JEDEC Basic Flash Parameter Table.
+		}
+		dw, err := d.readDwords(uint32(ph[4])|uint32(ph[5])<<8|uint32(ph[6])<<16, int(ph[3]))
+		if err != nil {
+			return err
+		}
+		pageSize, erases := parseBFPT(dw)
+		d.pageSize = pageSize
+		if len(erases) > 0 {
+			d.eraseSizes = erases
+		}
+		return nil
+	}
+	return errNoSFDP
+}
+
+func (d *Dev) readDwords(addr uint32, n int) ([]uint32, error) {
+	raw := make([]byte, n*4)
+	if err := d.readSFDP(addr, raw); err != nil {
+		return nil, err
+	}
+	dw := make([]uint32, n)
+	for k := range dw {
+		dw[k] = uint32(raw[4*k]) | uint32(raw[4*k+1])<<8 |
+			uint32(raw[4*k+2])<<16 | uint32(raw[4*k+3])<<24
+	}
+	return dw, nil
+}
+
+// parseBFPT extracts the page size and supported erase granularities from a
+// JEDEC Basic Flash Parameter Table, given as an array of its double words
+// (dw[0] is DWORD 1 in JESD216 numbering). Only the fields needed by
+// DetectGeometry are decoded: the page size (DWORD 11) and the four erase
+// type size/instruction pairs (DWORDs 8 and 9). Erase types marked unused
+// (size code 0x00 or 0xff) are skipped. The returned erases are sorted by
+// ascending size.
+func parseBFPT(dw []uint32) (pageSize uint32, erases []eraseSize) {
+	pageSize = defaultPageSize
+	if len(dw) > 10 {
+		if n := (dw[10] >> 4) & 0xf; n > 0 {
+			pageSize = 1 << n
+		}
+	}
+	addErase := func(sizeCode, instr byte) {
+		if sizeCode == 0x00 || sizeCode == 0xff {
+			return
+		}
+		erases = append(erases, eraseSize{cmd: instr, size: 1 << sizeCode})
+	}
+	if len(dw) > 7 {
+		addErase(byte(dw[7]), byte(dw[7]>>8))
+		addErase(byte(dw[7]>>16), byte(dw[7]>>24))
+	}
+	if len(dw) > 8 {
+		addErase(byte(dw[8]), byte(dw[8]>>8))
+		addErase(byte(dw[8]>>16), byte(dw[8]>>24))
+	}
+	sort.Slice(erases, func(i, j int) bool { return erases[i].size < erases[j].size })
+	return
+}
@@ -0,0 +1,220 @@
+// Package spinor drives standard SPI-NOR flash chips (Winbond W25Q, Micron
+// N25Q, Macronix MX25 and compatible parts) on top of spi.Master, using the
+// common JEDEC command set: RDID, READ/fast read, page program, sector/
+// block/chip erase and status register access.
+package spinor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ziutek/bitbang/spi"
+)
+
+// JEDEC command codes common to essentially all SPI-NOR flash chips.
+const (
+	cmdWREN    = 0x06
+	cmdWRDI    = 0x04
+	cmdRDSR    = 0x05
+	cmdWRSR    = 0x01
+	cmdREAD    = 0x03
+	cmdFREAD   = 0x0b
+	cmdPP      = 0x02
+	cmdSE      = 0x20
+	cmdBE      = 0xd8
+	cmdCE      = 0xc7
+	cmdRDID    = 0x9f
+	cmdRDSFDP  = 0x5a
+	statusWIP  = 0x01 // Write-in-progress bit of the status register.
+	pollPeriod = time.Millisecond
+)
+
+const defaultPageSize = 256
+
+// eraseSize pairs an erase instruction with the number of bytes it erases.
+type eraseSize struct {
+	cmd  byte
+	size uint32
+}
+
+// defaultEraseSizes are the granularities supported by essentially every
+// SPI-NOR chip, used when DetectGeometry hasn't been called or SFDP isn't
+// present. They are listed smallest first; Erase always picks the largest
+// one that fits and is address-aligned.
+var defaultEraseSizes = []eraseSize{
+	{cmdSE, 4 * 1024},
+	{cmdBE, 64 * 1024},
+}
+
+// Dev is a SPI-NOR flash device attached to a bit-banged SPI master.
+type Dev struct {
+	ma         *spi.Master
+	pageSize   uint32
+	eraseSizes []eraseSize
+}
+
+// New returns a Dev that talks to a SPI-NOR chip over ma. ma is configured
+// for the mode (MSBF, CPOL0, CPHA0) that SPI-NOR chips require. Page and
+// erase sizes default to the values common to all such chips; call
+// DetectGeometry to refine them from the chip's SFDP table.
+func New(ma *spi.Master) *Dev {
+	ma.Configure(spi.Config{Mode: spi.MSBF | spi.CPOL0 | spi.CPHA0, FrameLen: 1})
+	return &Dev{
+		ma:         ma,
+		pageSize:   defaultPageSize,
+		eraseSizes: defaultEraseSizes,
+	}
+}
+
+func addr3(cmd byte, addr uint32) []byte {
+	return []byte{cmd, byte(addr >> 16), byte(addr >> 8), byte(addr)}
+}
+
+// ReadID returns the JEDEC ID (manufacturer, memory type, capacity) read
+// using the RDID (0x9f) command.
+func (d *Dev) ReadID() ([3]byte, error) {
+	var id [3]byte
+	// Bytes clocked in while the command goes out are discarded; the reply
+	// is read while zeros are clocked out after it.
+	_, err := d.ma.WriteRead([]byte{cmdRDID}, nil, nil, id[:])
+	return id, err
+}
+
+// ReadStatus returns the value of the status register (RDSR, 0x05).
+func (d *Dev) ReadStatus() (byte, error) {
+	var sr [1]byte
+	_, err := d.ma.WriteRead([]byte{cmdRDSR}, nil, nil, sr[:])
+	return sr[0], err
+}
+
+// WriteStatus writes the status register (WRSR, 0x01), enabling writes
+// first as required by the command.
+func (d *Dev) WriteStatus(sr byte) error {
+	if err := d.writeEnable(); err != nil {
+		return err
+	}
+	_, err := d.ma.WriteRead([]byte{cmdWRSR, sr})
+	return err
+}
+
+func (d *Dev) writeEnable() error {
+	_, err := d.ma.WriteRead([]byte{cmdWREN})
+	return err
+}
+
+// WriteDisable clears the write enable latch (WRDI, 0x04).
+func (d *Dev) WriteDisable() error {
+	_, err := d.ma.WriteRead([]byte{cmdWRDI})
+	return err
+}
+
+// WaitReady polls the status register until the write-in-progress bit
+// clears, or ctx is done.
+func (d *Dev) WaitReady(ctx context.Context) error {
+	for {
+		sr, err := d.ReadStatus()
+		if err != nil {
+			return err
+		}
+		if sr&statusWIP == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollPeriod):
+		}
+	}
+}
+
+// Read reads len(buf) bytes starting at addr using the fast read command
+// (0x0b), which works at any clock speed unlike the plain READ command.
+func (d *Dev) Read(addr uint32, buf []byte) error {
+	cmd := append(addr3(cmdFREAD, addr), 0) // one dummy byte after the address.
+	_, err := d.ma.WriteRead(cmd, nil, nil, buf)
+	return err
+}
+
+// Program writes buf starting at addr, splitting it into page-aligned page
+// program (0x02) commands and waiting for each one to complete before
+// issuing the next.
+func (d *Dev) Program(addr uint32, buf []byte) error {
+	for len(buf) > 0 {
+		n := d.pageSize - addr%d.pageSize
+		if n > uint32(len(buf)) {
+			n = uint32(len(buf))
+		}
+		if err := d.pageProgram(addr, buf[:n]); err != nil {
+			return err
+		}
+		addr += n
+		buf = buf[n:]
+	}
+	return nil
+}
+
+func (d *Dev) pageProgram(addr uint32, page []byte) error {
+	if err := d.writeEnable(); err != nil {
+		return err
+	}
+	cmd := append(addr3(cmdPP, addr), page...)
+	if _, err := d.ma.WriteRead(cmd); err != nil {
+		return err
+	}
+	return d.WaitReady(context.Background())
+}
+
+// errNoFit is returned by Erase when no supported erase granularity both
+// fits within the remaining size and is aligned to the current address.
+var errNoFit = errors.New("spinor: size too small or not aligned to any erase size")
+
+// Erase erases the size bytes starting at addr, repeatedly choosing the
+// largest erase granularity (sector, block, ...) that both fits in the
+// remaining range and is aligned to addr, waiting for each erase to
+// complete before issuing the next. It returns errNoFit, without erasing
+// anything more, if what's left doesn't fit any supported granularity (eg.
+// size smaller than the smallest sector, or addr/size not aligned to it).
+func (d *Dev) Erase(addr, size uint32) error {
+	for size > 0 {
+		es, ok := d.bestEraseSize(addr, size)
+		if !ok {
+			return errNoFit
+		}
+		if err := d.writeEnable(); err != nil {
+			return err
+		}
+		if _, err := d.ma.WriteRead(addr3(es.cmd, addr)); err != nil {
+			return err
+		}
+		if err := d.WaitReady(context.Background()); err != nil {
+			return err
+		}
+		addr += es.size
+		size -= es.size
+	}
+	return nil
+}
+
+// bestEraseSize returns the largest erase granularity that both fits within
+// size and is aligned to addr. ok is false if none does.
+func (d *Dev) bestEraseSize(addr, size uint32) (best eraseSize, ok bool) {
+	for _, es := range d.eraseSizes {
+		if es.size <= size && addr%es.size == 0 && es.size > best.size {
+			best, ok = es, true
+		}
+	}
+	return best, ok
+}
+
+// EraseAll erases the whole chip (chip erase, 0xc7) and waits for it to
+// complete.
+func (d *Dev) EraseAll() error {
+	if err := d.writeEnable(); err != nil {
+		return err
+	}
+	if _, err := d.ma.WriteRead([]byte{cmdCE}); err != nil {
+		return err
+	}
+	return d.WaitReady(context.Background())
+}
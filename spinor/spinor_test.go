@@ -0,0 +1,184 @@
+package spinor
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ziutek/bitbang/spi"
+)
+
+// fakedrv is a testdrv with independent read and write buffers: Dev drives a
+// real spi.Master, which writes and reads concurrently in separate
+// goroutines, so the two buffers let r hold a canned (here all-zero, ie.
+// status register with WIP clear) slave response while w captures exactly
+// what Dev put on the wire.
+type fakedrv struct {
+	r *bytes.Buffer
+	w *bytes.Buffer
+}
+
+func (d *fakedrv) Read(p []byte) (int, error)  { return d.r.Read(p) }
+func (d *fakedrv) Write(p []byte) (int, error) { return d.w.Write(p) }
+func (*fakedrv) Flush() error                  { return nil }
+
+const (
+	tsclk = 0x01
+	tmosi = 0x10
+)
+
+// decodeMOSICall decodes the n data bytes a single spi.Master.WriteRead call
+// put on the wire (MSBF, CPOL0, CPHA0), ie. the first n*16 bytes of raw;
+// the trailing idle byte End() appends is not part of the n*16 and must be
+// skipped by the caller before decoding the next call.
+func decodeMOSICall(raw []byte, n int) []byte {
+	out := make([]byte, n)
+	for k := 0; k < n; k++ {
+		chunk := raw[k*16 : k*16+16]
+		var u byte
+		for i := 1; i < 16; i += 2 {
+			u <<= 1
+			if chunk[i]&tmosi != 0 {
+				u |= 1
+			}
+		}
+		out[k] = u
+	}
+	return out
+}
+
+// newTestDev returns a Dev backed by a fakedrv whose r is large enough and
+// all-zero, so every ReadStatus Dev issues (eg. via WaitReady) decodes to a
+// status register of 0: WIP clear, so WaitReady returns after a single poll.
+func newTestDev() (*Dev, *fakedrv) {
+	drv := &fakedrv{r: bytes.NewBuffer(make([]byte, 64*1024)), w: new(bytes.Buffer)}
+	ma := spi.NewMaster(drv, tsclk, tmosi, 0x20)
+	return New(ma), drv
+}
+
+// TestProgramWireBytes checks the actual command, address and data bytes
+// Program puts on the wire: WREN, then page program (cmdPP + 3-byte address
+// + the page data), then the RDSR poll WaitReady issues to confirm the
+// write completed.
+func TestProgramWireBytes(t *testing.T) {
+	d, drv := newTestDev()
+	page := []byte{0x11, 0x22}
+	addr := uint32(0x001000)
+	if err := d.Program(addr, page); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := drv.w.Bytes()
+
+	wren := decodeMOSICall(raw, 1)
+	raw = raw[1*16+1:]
+	if !bytes.Equal(wren, []byte{cmdWREN}) {
+		t.Errorf("WREN command = %#v, want %#v", wren, []byte{cmdWREN})
+	}
+
+	ppLen := 4 + len(page)
+	pp := decodeMOSICall(raw, ppLen)
+	raw = raw[ppLen*16+1:]
+	wantPP := append(addr3(cmdPP, addr), page...)
+	if !bytes.Equal(pp, wantPP) {
+		t.Errorf("page program command = %#v, want %#v", pp, wantPP)
+	}
+
+	rdsr := decodeMOSICall(raw, 1)
+	if !bytes.Equal(rdsr, []byte{cmdRDSR}) {
+		t.Errorf("RDSR command = %#v, want %#v", rdsr, []byte{cmdRDSR})
+	}
+}
+
+// TestEraseWireBytes checks the actual command and address bytes Erase puts
+// on the wire: WREN, then the erase command (sector erase here, since size
+// only fits a 4KiB sector), then the RDSR poll WaitReady issues.
+func TestEraseWireBytes(t *testing.T) {
+	d, drv := newTestDev()
+	addr := uint32(0x002000)
+	if err := d.Erase(addr, 4*1024); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := drv.w.Bytes()
+
+	wren := decodeMOSICall(raw, 1)
+	raw = raw[1*16+1:]
+	if !bytes.Equal(wren, []byte{cmdWREN}) {
+		t.Errorf("WREN command = %#v, want %#v", wren, []byte{cmdWREN})
+	}
+
+	se := decodeMOSICall(raw, 4)
+	raw = raw[4*16+1:]
+	wantSE := addr3(cmdSE, addr)
+	if !bytes.Equal(se, wantSE) {
+		t.Errorf("erase command = %#v, want %#v", se, wantSE)
+	}
+
+	rdsr := decodeMOSICall(raw, 1)
+	if !bytes.Equal(rdsr, []byte{cmdRDSR}) {
+		t.Errorf("RDSR command = %#v, want %#v", rdsr, []byte{cmdRDSR})
+	}
+}
+
+func TestAddr3(t *testing.T) {
+	got := addr3(cmdPP, 0x123456)
+	want := []byte{cmdPP, 0x12, 0x34, 0x56}
+	if !bytes.Equal(got, want) {
+		t.Errorf("addr3() = %#v, want %#v", got, want)
+	}
+}
+
+func TestBestEraseSize(t *testing.T) {
+	d := &Dev{eraseSizes: defaultEraseSizes}
+	cases := []struct {
+		addr, size uint32
+		want       uint32
+		ok         bool
+	}{
+		{0, 4 * 1024, 4 * 1024, true},
+		{0, 64 * 1024, 64 * 1024, true},
+		{4 * 1024, 64 * 1024, 4 * 1024, true}, // not block-aligned, falls back to sector.
+		{0, 1024, 0, false},                   // smaller than the smallest granularity: no fit.
+		{4*1024 + 1, 64 * 1024, 0, false},     // not aligned to any granularity.
+	}
+	for _, c := range cases {
+		got, ok := d.bestEraseSize(c.addr, c.size)
+		if ok != c.ok || got.size != c.want {
+			t.Errorf("bestEraseSize(%d, %d) = %d, %v, want %d, %v", c.addr, c.size, got.size, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestEraseNoFit(t *testing.T) {
+	d := &Dev{eraseSizes: defaultEraseSizes}
+	if err := d.Erase(0, 1024); err != errNoFit {
+		t.Errorf("Erase(0, 1024) = %v, want errNoFit", err)
+	}
+}
+
+func TestParseBFPT(t *testing.T) {
+	dw := make([]uint32, 11)
+	dw[7] = 12 | 0x20<<8 | 16<<16 | 0xd8<<24 // erase type 1: 4KiB/0x20, type 2: 64KiB/0xd8.
+	dw[8] = 0xffffffff                       // erase types 3 and 4 unused.
+	dw[10] = 8 << 4                          // page size 2^8 = 256.
+
+	pageSize, erases := parseBFPT(dw)
+	if pageSize != 256 {
+		t.Errorf("pageSize = %d, want 256", pageSize)
+	}
+	want := []eraseSize{{0x20, 4096}, {0xd8, 65536}}
+	if !reflect.DeepEqual(erases, want) {
+		t.Errorf("erases = %+v, want %+v", erases, want)
+	}
+}
+
+func TestParseBFPTShortTable(t *testing.T) {
+	pageSize, erases := parseBFPT(nil)
+	if pageSize != defaultPageSize {
+		t.Errorf("pageSize = %d, want %d", pageSize, defaultPageSize)
+	}
+	if erases != nil {
+		t.Errorf("erases = %+v, want nil", erases)
+	}
+}
@@ -0,0 +1,110 @@
+package bitbang
+
+import "sync"
+
+// Dir says whether a byte seen by a Tap was written to or read from the
+// wrapped driver.
+type Dir int
+
+const (
+	DirWrite Dir = iota
+	DirRead
+)
+
+// Channel names one bit of a bit-banged word, eg. {"SCLK", 0x01}.
+type Channel struct {
+	Name string
+	Mask byte
+}
+
+// ChannelLayout names every channel carried by the bytes a Tap observes, in
+// display order.
+type ChannelLayout []Channel
+
+// TapSink receives the bytes a Tap observes, in the order they occur.
+type TapSink interface {
+	// Sample records byte b, written or read at the given monotonically
+	// increasing sample index.
+	Sample(index uint64, dir Dir, b byte) error
+	// Flush is called whenever the wrapped driver's Flush is called.
+	Flush() error
+	// Close finalizes the sink, eg. writing any trailing records its
+	// format requires. The Tap itself is never closed automatically.
+	Close() error
+}
+
+// Tap wraps a SyncDriver and forwards every byte written to or read from it
+// to a TapSink, alongside a monotonically increasing sample index. This
+// turns any bit-banged transfer into something that can be inspected after
+// the fact, eg. opened in Wireshark (via PcapSink) or PulseView (via
+// VCDSink). Write and Read may be called concurrently, as spi.Master does
+// internally for WriteRead; Tap serializes its own index/sink bookkeeping so
+// that doesn't race, though the two directions' samples may interleave in
+// either order.
+type Tap struct {
+	inner  SyncDriver
+	sink   TapSink
+	layout ChannelLayout
+	mu     sync.Mutex // guards idx and sink.Sample, called concurrently by Write and Read
+	idx    uint64
+}
+
+// NewTap returns a Tap that forwards reads/writes to inner while reporting
+// every byte to sink. layout names the channels the captured bytes carry;
+// Tap itself only needs it to hand back via Layout, since sinks such as
+// PcapSink and VCDSink are configured with their own copy up front.
+func NewTap(inner SyncDriver, sink TapSink, layout ChannelLayout) *Tap {
+	return &Tap{inner: inner, sink: sink, layout: layout}
+}
+
+// Layout returns the channel layout Tap was constructed with.
+func (t *Tap) Layout() ChannelLayout {
+	return t.layout
+}
+
+// Write writes data to the wrapped driver and reports each written byte to
+// the sink before returning.
+func (t *Tap) Write(data []byte) (int, error) {
+	n, err := t.inner.Write(data)
+	if serr := t.report(DirWrite, data[:n]); err == nil {
+		err = serr
+	}
+	return n, err
+}
+
+// Read reads into data from the wrapped driver and reports each read byte
+// to the sink before returning.
+func (t *Tap) Read(data []byte) (int, error) {
+	n, err := t.inner.Read(data)
+	if serr := t.report(DirRead, data[:n]); err == nil {
+		err = serr
+	}
+	return n, err
+}
+
+func (t *Tap) report(dir Dir, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, b := range data {
+		if err := t.sink.Sample(t.idx, dir, b); err != nil {
+			return err
+		}
+		t.idx++
+	}
+	return nil
+}
+
+// Flush flushes the wrapped driver, then the sink.
+func (t *Tap) Flush() error {
+	err := t.inner.Flush()
+	if serr := t.sink.Flush(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// Close finalizes the sink. It does not touch the wrapped driver, which has
+// no Close method of its own in the SyncDriver interface.
+func (t *Tap) Close() error {
+	return t.sink.Close()
+}
@@ -0,0 +1,108 @@
+package bitbang
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+type recordSink struct {
+	samples []byte
+	dirs    []Dir
+	flushed bool
+	closed  bool
+}
+
+func (s *recordSink) Sample(_ uint64, dir Dir, b byte) error {
+	s.samples = append(s.samples, b)
+	s.dirs = append(s.dirs, dir)
+	return nil
+}
+
+func (s *recordSink) Flush() error { s.flushed = true; return nil }
+func (s *recordSink) Close() error { s.closed = true; return nil }
+
+type loopbackDrv struct {
+	*bytes.Buffer
+}
+
+func (loopbackDrv) Flush() error { return nil }
+
+func TestTapReportsWritesAndReads(t *testing.T) {
+	sink := &recordSink{}
+	drv := loopbackDrv{bytes.NewBuffer([]byte{0xaa, 0xbb})}
+	tap := NewTap(drv, sink, ChannelLayout{{"SCLK", 0x01}})
+
+	if _, err := tap.Write([]byte{0x55}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2)
+	if _, err := tap.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := tap.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wantSamples := []byte{0x55, 0xaa, 0xbb}
+	wantDirs := []Dir{DirWrite, DirRead, DirRead}
+	if !bytes.Equal(sink.samples, wantSamples) {
+		t.Errorf("samples = %#v, want %#v", sink.samples, wantSamples)
+	}
+	for i, d := range sink.dirs {
+		if d != wantDirs[i] {
+			t.Errorf("dirs[%d] = %v, want %v", i, d, wantDirs[i])
+		}
+	}
+	if !sink.flushed {
+		t.Error("sink was not flushed")
+	}
+	if !sink.closed {
+		t.Error("sink was not closed")
+	}
+}
+
+type rwDrv struct {
+	r *bytes.Buffer
+	w *bytes.Buffer
+}
+
+func (d rwDrv) Read(p []byte) (int, error)  { return d.r.Read(p) }
+func (d rwDrv) Write(p []byte) (int, error) { return d.w.Write(p) }
+func (rwDrv) Flush() error                  { return nil }
+
+// TestTapConcurrentWriteRead exercises Write and Read at the same time, as
+// spi.Master.WriteRead does against the driver it wraps in a Tap. Run with
+// -race: report must serialize its index increment and Sample call.
+func TestTapConcurrentWriteRead(t *testing.T) {
+	const n = 1000
+	drv := rwDrv{r: bytes.NewBuffer(make([]byte, n)), w: new(bytes.Buffer)}
+	tap := NewTap(drv, &recordSink{}, nil)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 1)
+		for i := 0; i < n; i++ {
+			if _, err := tap.Write(buf); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 1)
+		for i := 0; i < n; i++ {
+			if _, err := tap.Read(buf); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
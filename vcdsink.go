@@ -0,0 +1,89 @@
+package bitbang
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// VCDSink writes captured samples to w as a Value Change Dump file naming
+// each channel from layout, in the dialect sigrok/PulseView import. There's
+// no wall clock available to a TapSink, so the sample index is used
+// directly as the VCD timestamp, one nominal tick apart.
+type VCDSink struct {
+	w      io.Writer
+	layout ChannelLayout
+	ids    []byte
+	last   []int8 // -1 means "not yet written".
+	err    error
+}
+
+// NewVCDSink writes the VCD header declaring one single-bit wire per
+// channel in layout and returns a VCDSink ready to receive samples.
+func NewVCDSink(w io.Writer, layout ChannelLayout) (*VCDSink, error) {
+	s := &VCDSink{
+		w:      w,
+		layout: layout,
+		ids:    make([]byte, len(layout)),
+		last:   make([]int8, len(layout)),
+	}
+	for i := range s.last {
+		s.ids[i] = byte('!' + i) // First printable VCD identifier characters.
+		s.last[i] = -1
+	}
+	if err := s.writeHeader(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *VCDSink) writeHeader() error {
+	var buf bytes.Buffer
+	fmt.Fprint(&buf, "$timescale 1 ns $end\n")
+	fmt.Fprint(&buf, "$scope module bitbang $end\n")
+	for i, ch := range s.layout {
+		fmt.Fprintf(&buf, "$var wire 1 %c %s $end\n", s.ids[i], ch.Name)
+	}
+	fmt.Fprint(&buf, "$upscope $end\n")
+	fmt.Fprint(&buf, "$enddefinitions $end\n")
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// Sample emits a #<index> timestamp followed by one value-change line for
+// every channel whose bit changed since the last Sample call. If no channel
+// changed, it writes nothing, not even the timestamp.
+func (s *VCDSink) Sample(index uint64, _ Dir, b byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	var buf bytes.Buffer
+	for i, ch := range s.layout {
+		v := int8(0)
+		if b&ch.Mask != 0 {
+			v = 1
+		}
+		if v == s.last[i] {
+			continue
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "#%d\n", index)
+		}
+		fmt.Fprintf(&buf, "%d%c\n", v, s.ids[i])
+		s.last[i] = v
+	}
+	if buf.Len() > 0 {
+		_, s.err = s.w.Write(buf.Bytes())
+	}
+	return s.err
+}
+
+// Flush is a no-op: every Sample call already writes complete lines.
+func (s *VCDSink) Flush() error {
+	return s.err
+}
+
+// Close is a no-op: this VCD dialect doesn't need a trailer.
+func (s *VCDSink) Close() error {
+	return s.err
+}